@@ -0,0 +1,46 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCapabilityNames(t *testing.T) {
+	// CAP_CHOWN (bit 0) and CAP_NET_ADMIN (bit 12), as reported by a
+	// real-world CapEff value for a container with NET_ADMIN added.
+	mask := uint64(1<<0 | 1<<12)
+	got := capabilityNames(mask)
+	want := []string{"CAP_CHOWN", "CAP_NET_ADMIN"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestLookupName(t *testing.T) {
+	names := map[int]string{0: "root", 1000: "app"}
+
+	if got := lookupName(names, 0); got != "root" {
+		t.Errorf("got %q, want %q", got, "root")
+	}
+	if got := lookupName(names, 4242); got != "4242" {
+		t.Errorf("unmapped id should fall back to its numeric string, got %q", got)
+	}
+}
@@ -0,0 +1,227 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package nsutil lets callers enter another process's Linux namespaces for
+// the duration of a function call, and resolve the bind-mounted named
+// network namespaces that "ip netns" creates under /run/netns.
+package nsutil
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog"
+)
+
+// NsHandle is an open file descriptor referring to one namespace, as found
+// at /proc/<pid>/ns/<kind>.
+type NsHandle int
+
+// noHandle is the closed handle returned alongside every error.
+const noHandle NsHandle = -1
+
+// IsOpen reports whether ns refers to an open file descriptor.
+func (ns NsHandle) IsOpen() bool {
+	return ns >= 0
+}
+
+// Close closes the underlying file descriptor.
+func (ns NsHandle) Close() error {
+	if !ns.IsOpen() {
+		return nil
+	}
+	return syscall.Close(int(ns))
+}
+
+// cloneFlags maps the namespace kind names used under /proc/<pid>/ns to the
+// CLONE_NEW* flag setns(2) expects for that kind.
+var cloneFlags = map[string]int{
+	"net":  unix.CLONE_NEWNET,
+	"mnt":  unix.CLONE_NEWNS,
+	"pid":  unix.CLONE_NEWPID,
+	"uts":  unix.CLONE_NEWUTS,
+	"ipc":  unix.CLONE_NEWIPC,
+	"user": unix.CLONE_NEWUSER,
+}
+
+func cloneFlag(kind string) (int, error) {
+	flag, ok := cloneFlags[kind]
+	if !ok {
+		return 0, fmt.Errorf("nsutil: unsupported namespace kind %q", kind)
+	}
+	return flag, nil
+}
+
+// GetFromPid opens the given kind of namespace ("net", "mnt", "pid", "uts",
+// "ipc" or "user") belonging to pid.
+func GetFromPid(pid int, kind string) (NsHandle, error) {
+	if _, err := cloneFlag(kind); err != nil {
+		return noHandle, err
+	}
+	return openNsFile(fmt.Sprintf("/proc/%d/ns/%s", pid, kind))
+}
+
+// Set moves the calling OS thread into the namespace referred to by ns.
+// Callers are responsible for having pinned the calling goroutine to its
+// current OS thread with runtime.LockOSThread; see Do for the common case.
+func Set(ns NsHandle, kind string) error {
+	flag, err := cloneFlag(kind)
+	if err != nil {
+		return err
+	}
+	if !ns.IsOpen() {
+		return fmt.Errorf("nsutil: Set called with a closed namespace handle")
+	}
+	return unix.Setns(int(ns), flag)
+}
+
+// Do locks the calling goroutine to its current OS thread, enters the
+// requested kinds of namespace belonging to pid, runs fn, and restores the
+// thread's original namespaces before returning - even if fn panics or
+// returns an error partway through entering the namespaces.
+func Do(pid int, kinds []string, fn func() error) error {
+	if len(kinds) == 0 {
+		return fn()
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	originals := make([]NsHandle, 0, len(kinds))
+	defer func() {
+		for i := len(originals) - 1; i >= 0; i-- {
+			if err := Set(originals[i], kinds[i]); err != nil {
+				klog.Errorf("nsutil: restoring %s namespace: %v", kinds[i], err)
+			}
+			originals[i].Close()
+		}
+	}()
+
+	for _, kind := range kinds {
+		orig, err := openNsFile("/proc/self/ns/" + kind)
+		if err != nil {
+			return fmt.Errorf("nsutil: saving current %s namespace: %w", kind, err)
+		}
+		originals = append(originals, orig)
+	}
+
+	// Every target handle must be opened before any Set call: GetFromPid
+	// resolves pid through /proc under the *current* mount namespace, and
+	// once Set switches that to the target's own mount namespace - a
+	// container normally runs its own /proc - a host-relative pid may no
+	// longer exist there, or worse, may resolve to an unrelated process
+	// reusing that number inside the target's pid namespace.
+	targets := make([]NsHandle, 0, len(kinds))
+	defer func() {
+		for _, target := range targets {
+			target.Close()
+		}
+	}()
+	for _, kind := range kinds {
+		target, err := GetFromPid(pid, kind)
+		if err != nil {
+			return fmt.Errorf("nsutil: opening pid %d's %s namespace: %w", pid, kind, err)
+		}
+		targets = append(targets, target)
+	}
+
+	for i, kind := range kinds {
+		if err := Set(targets[i], kind); err != nil {
+			return fmt.Errorf("nsutil: entering pid %d's %s namespace: %w", pid, kind, err)
+		}
+	}
+
+	return fn()
+}
+
+// namedNsDirs are the directories "ip netns" bind-mounts named network
+// namespaces under, searched in order.
+var namedNsDirs = []string{"/run/netns", "/var/run/netns"}
+
+// GetFromName resolves a named network namespace previously created with
+// "ip netns add <name>" or NewNamed.
+func GetFromName(name string) (NsHandle, error) {
+	var lastErr error
+	for _, dir := range namedNsDirs {
+		ns, err := openNsFile(dir + "/" + name)
+		if err == nil {
+			return ns, nil
+		}
+		if !os.IsNotExist(err) {
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return noHandle, lastErr
+	}
+	return noHandle, fmt.Errorf("nsutil: named network namespace %q not found under %s", name, namedNsDirs)
+}
+
+// NewNamed creates a new network namespace and binds it to
+// /run/netns/<name>, in the same layout "ip netns add <name>" produces, and
+// returns a handle to it. The calling OS thread's network namespace is left
+// unchanged on return.
+func NewNamed(name string) (NsHandle, error) {
+	if err := os.MkdirAll(namedNsDirs[0], 0755); err != nil {
+		return noHandle, fmt.Errorf("nsutil: creating %s: %w", namedNsDirs[0], err)
+	}
+	nsPath := namedNsDirs[0] + "/" + name
+
+	f, err := os.OpenFile(nsPath, os.O_CREATE|os.O_EXCL|os.O_RDONLY, 0644)
+	if err != nil {
+		return noHandle, fmt.Errorf("nsutil: creating bind-mount target %s: %w", nsPath, err)
+	}
+	f.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	orig, err := openNsFile("/proc/self/ns/net")
+	if err != nil {
+		os.Remove(nsPath)
+		return noHandle, fmt.Errorf("nsutil: saving current net namespace: %w", err)
+	}
+	defer func() {
+		if err := Set(orig, "net"); err != nil {
+			klog.Errorf("nsutil: restoring net namespace after NewNamed(%q): %v", name, err)
+		}
+		orig.Close()
+	}()
+
+	if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+		os.Remove(nsPath)
+		return noHandle, fmt.Errorf("nsutil: unsharing net namespace: %w", err)
+	}
+	if err := unix.Mount("/proc/self/ns/net", nsPath, "none", unix.MS_BIND, ""); err != nil {
+		os.Remove(nsPath)
+		return noHandle, fmt.Errorf("nsutil: bind-mounting new net namespace at %s: %w", nsPath, err)
+	}
+
+	return GetFromName(name)
+}
+
+func openNsFile(path string) (NsHandle, error) {
+	fd, err := syscall.Open(path, syscall.O_RDONLY, 0)
+	if err != nil {
+		return noHandle, err
+	}
+	return NsHandle(fd), nil
+}
@@ -0,0 +1,35 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nsutil
+
+import "testing"
+
+func TestCloneFlagSupportedKinds(t *testing.T) {
+	for _, kind := range []string{"net", "mnt", "pid", "uts", "ipc", "user"} {
+		if _, err := cloneFlag(kind); err != nil {
+			t.Errorf("cloneFlag(%q) returned an error: %v", kind, err)
+		}
+	}
+}
+
+func TestCloneFlagUnsupportedKind(t *testing.T) {
+	if _, err := cloneFlag("bogus"); err == nil {
+		t.Fatal("expected an error for an unsupported namespace kind")
+	}
+}
@@ -0,0 +1,264 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procfs
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrPermissionDenied is returned by ListPorts when /proc/net/* cannot be
+// read, e.g. under Android or a hardened container where that path is
+// restricted even though /proc/<pid> itself is visible.
+var ErrPermissionDenied = errors.New("procfs: permission denied reading /proc/net")
+
+// all of the /proc/net files ListPorts knows how to parse, keyed by the
+// protocol name reported on Port.Proto.
+var allProtocols = []string{"tcp", "tcp6", "udp", "udp6"}
+
+// tcpStates maps the hex socket state reported in /proc/net/tcp{,6} to its
+// name, per the TCP_* enum in include/net/tcp_states.h.
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+// Port describes one socket found in /proc/net/{tcp,udp}{,6}, joined with the
+// PID that owns its file descriptor.
+type Port struct {
+	Proto                 string
+	LocalAddr, RemoteAddr net.IP
+	LocalPort, RemotePort uint16
+	State                 string
+	Inode                 uint64
+	// PID and Comm are zero/empty if no process's open file descriptors
+	// referenced this socket's inode at scan time (e.g. it is in a
+	// TIME_WAIT state with no owning process left).
+	PID  int
+	Comm string
+}
+
+// PortOpts controls which sockets ListPorts returns.
+type PortOpts struct {
+	// ListenersOnly restricts TCP results to sockets in the LISTEN state.
+	// It has no effect on UDP, which has no equivalent of LISTEN.
+	ListenersOnly bool
+	// Protocols restricts results to this subset of "tcp", "tcp6", "udp",
+	// "udp6". A nil or empty slice means all four.
+	Protocols []string
+	// SkipLoopback omits sockets whose local or remote address is a
+	// loopback address.
+	SkipLoopback bool
+}
+
+// ListPorts enumerates sockets from /proc/net/{tcp,udp}{,6} and resolves the
+// owning PID of each by joining on its inode against a single pass over
+// every process's /proc/<pid>/fd entries, so the whole join costs
+// O(sockets + fds) rather than a stat per socket.
+func ListPorts(opts PortOpts) ([]Port, error) {
+	protocols := opts.Protocols
+	if len(protocols) == 0 {
+		protocols = allProtocols
+	}
+
+	inodeToPID, err := inodesToPIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var ports []Port
+	for _, proto := range protocols {
+		data, err := ioutil.ReadFile("/proc/net/" + proto)
+		if err != nil {
+			if os.IsPermission(err) {
+				return nil, fmt.Errorf("%w: %s", ErrPermissionDenied, err)
+			}
+			if os.IsNotExist(err) {
+				// IPv6 files are absent when the host has IPv6 disabled.
+				continue
+			}
+			return nil, err
+		}
+
+		entries, err := parseProcNet(proto, string(data))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, port := range entries {
+			if opts.ListenersOnly && strings.HasPrefix(proto, "tcp") && port.State != "LISTEN" {
+				continue
+			}
+			if opts.SkipLoopback && (port.LocalAddr.IsLoopback() || port.RemoteAddr.IsLoopback()) {
+				continue
+			}
+			if pid, ok := inodeToPID[port.Inode]; ok {
+				port.PID = pid
+				if comm, err := (Proc{PID: pid}).Comm(); err == nil {
+					port.Comm = comm
+				}
+			}
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// parseProcNet parses the contents of one /proc/net/{tcp,udp}{,6} file. The
+// first line is a column header and is skipped; the remaining lines have the
+// form:
+//
+//	sl  local_address rem_address   st tx_queue:rx_queue tr:tm->when retrnsmt   uid  timeout inode
+//	 0: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 28073 ...
+func parseProcNet(proto, content string) ([]Port, error) {
+	lines := strings.Split(content, "\n")
+	if len(lines) > 0 {
+		lines = lines[1:] // drop the header
+	}
+
+	var ports []Port
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+
+		localAddr, localPort, err := parseHexAddrPort(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("parsing local address %q: %w", fields[1], err)
+		}
+		remoteAddr, remotePort, err := parseHexAddrPort(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("parsing remote address %q: %w", fields[2], err)
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing inode %q: %w", fields[9], err)
+		}
+
+		state := strings.ToUpper(fields[3])
+		if name, ok := tcpStates[state]; ok {
+			state = name
+		}
+
+		ports = append(ports, Port{
+			Proto:      proto,
+			LocalAddr:  localAddr,
+			LocalPort:  localPort,
+			RemoteAddr: remoteAddr,
+			RemotePort: remotePort,
+			State:      state,
+			Inode:      inode,
+		})
+	}
+	return ports, nil
+}
+
+// parseHexAddrPort parses a "<hex-address>:<hex-port>" field as found in
+// /proc/net/{tcp,udp}{,6}.
+func parseHexAddrPort(field string) (net.IP, uint16, error) {
+	parts := strings.SplitN(field, ":", 2)
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("expected \"address:port\"")
+	}
+	ip, err := decodeHexIP(parts[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ip, uint16(port), nil
+}
+
+// decodeHexIP decodes the address encoding used by /proc/net/{tcp,udp}{,6}:
+// each 4-byte group is stored in host byte order (little-endian on every
+// platform Linux runs procfs on), so within each group the bytes are
+// reversed relative to network byte order.
+func decodeHexIP(hexAddr string) (net.IP, error) {
+	raw, err := hex.DecodeString(hexAddr)
+	if err != nil {
+		return nil, err
+	}
+	switch len(raw) {
+	case 4:
+		return net.IPv4(raw[3], raw[2], raw[1], raw[0]), nil
+	case 16:
+		ip := make(net.IP, 16)
+		for i := 0; i < 16; i += 4 {
+			ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+		}
+		return ip, nil
+	default:
+		return nil, fmt.Errorf("unexpected address length %d", len(raw))
+	}
+}
+
+// inodesToPIDs builds a map from socket inode to owning PID by making a
+// single pass over every process's /proc/<pid>/fd entries, looking for
+// symlinks of the form "socket:[<inode>]".
+func inodesToPIDs() (map[uint64]int, error) {
+	procs, err := allProcs()
+	if err != nil {
+		return nil, err
+	}
+
+	inodeToPID := make(map[uint64]int)
+	for _, p := range procs {
+		fdDir := p.path("fd")
+		names, err := ioutil.ReadDir(fdDir)
+		if err != nil {
+			// The process may have exited, or we may lack permission to
+			// inspect it (e.g. it belongs to another user); either way,
+			// just skip it and keep joining what we can.
+			continue
+		}
+		for _, fd := range names {
+			target, err := os.Readlink(fdDir + "/" + fd.Name())
+			if err != nil {
+				continue
+			}
+			if !strings.HasPrefix(target, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			inodeToPID[inode] = p.PID
+		}
+	}
+	return inodeToPID, nil
+}
@@ -0,0 +1,78 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procfs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDecodeHexIP(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want net.IP
+	}{
+		{name: "v4 loopback", hex: "0100007F", want: net.IPv4(127, 0, 0, 1)},
+		{name: "v4 any", hex: "00000000", want: net.IPv4(0, 0, 0, 0)},
+		{name: "v6 loopback", hex: "00000000000000000000000001000000", want: net.ParseIP("::1")},
+		{name: "invalid hex", hex: "zz", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeHexIP(tt.hex)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProcNet(t *testing.T) {
+	content := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+		"   0: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 28073 1 0000000000000000 100 0 0 10 0\n" +
+		"   1: 0100007F:C35C 0100007F:0277 01 00000000:00000000 00:00000000 00000000  1000        0 28074 1 0000000000000000 20 4 30 10 -1\n"
+
+	ports, err := parseProcNet("tcp", content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ports) != 2 {
+		t.Fatalf("got %d ports, want 2", len(ports))
+	}
+
+	listener := ports[0]
+	if listener.State != "LISTEN" || listener.LocalPort != 0x277 || listener.Inode != 28073 {
+		t.Errorf("unexpected listener: %+v", listener)
+	}
+	established := ports[1]
+	if established.State != "ESTABLISHED" || established.RemotePort != 0x277 || established.Inode != 28074 {
+		t.Errorf("unexpected established conn: %+v", established)
+	}
+}
@@ -0,0 +1,375 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procfs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Proc provides access to the information in /proc/<pid> for a single
+// process. It is a thin handle: constructing one does no I/O, and every
+// accessor re-reads the underlying /proc file so the returned data is always
+// current.
+type Proc struct {
+	PID int
+}
+
+// ProcStat holds the fields of /proc/<pid>/stat that are useful outside of
+// the kernel scheduler internals. See proc(5) for field semantics; Utime,
+// Stime and StartTime are expressed in clock ticks, and RSS is expressed in
+// pages, exactly as the kernel reports them.
+type ProcStat struct {
+	PID       int
+	Comm      string
+	State     string
+	PPID      int
+	PGRP      int
+	Utime     uint64
+	Stime     uint64
+	StartTime uint64
+	RSS       int64
+	VSize     uint64
+}
+
+// ProcStatus holds selected fields of /proc/<pid>/status, which reports the
+// same process in a more parse-friendly "Key:\tvalue" form than
+// /proc/<pid>/stat.
+type ProcStatus struct {
+	// Uid holds the real, effective, saved and filesystem UIDs, in that order.
+	Uid []int
+	// Gid holds the real, effective, saved and filesystem GIDs, in that order.
+	Gid    []int
+	Groups []int
+	// VmRSS is the resident set size, in kB.
+	VmRSS uint64
+	// NSpid holds the PID as seen from each level of nested PID namespaces,
+	// outermost first.
+	NSpid []int
+	// CapEff is the effective capability set, as the raw bitmask reported by
+	// the kernel.
+	CapEff uint64
+}
+
+// Cgroup holds one parsed line of /proc/<pid>/cgroup.
+type Cgroup struct {
+	// HierarchyID is the cgroup v1 hierarchy ID, or 0 for the cgroup v2
+	// unified hierarchy.
+	HierarchyID int
+	// Controllers lists the v1 subsystems attached to this hierarchy; it is
+	// empty for the v2 unified hierarchy.
+	Controllers []string
+	Path        string
+}
+
+func (p Proc) path(elem ...string) string {
+	return filepath.Join(append([]string{procPath, strconv.Itoa(p.PID)}, elem...)...)
+}
+
+// Cmdline returns the process's command line, split on the NUL bytes the
+// kernel uses to separate arguments.
+func (p Proc) Cmdline() ([]string, error) {
+	data, err := ioutil.ReadFile(p.path("cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimRight(data, "\x00")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	parts := bytes.Split(data, []byte{0})
+	cmdline := make([]string, len(parts))
+	for i, part := range parts {
+		cmdline[i] = string(part)
+	}
+	return cmdline, nil
+}
+
+// Comm returns the process's command name, as the kernel truncates and
+// reports it in /proc/<pid>/comm.
+func (p Proc) Comm() (string, error) {
+	data, err := ioutil.ReadFile(p.path("comm"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Stat reads and parses /proc/<pid>/stat.
+func (p Proc) Stat() (ProcStat, error) {
+	data, err := ioutil.ReadFile(p.path("stat"))
+	if err != nil {
+		return ProcStat{}, err
+	}
+	stat, err := parseProcStat(string(data))
+	if err != nil {
+		return ProcStat{}, fmt.Errorf("procfs: parsing stat for pid %d: %w", p.PID, err)
+	}
+	stat.PID = p.PID
+	return stat, nil
+}
+
+func parseProcStat(line string) (ProcStat, error) {
+	// comm is whatever the kernel put between the first '(' and the last
+	// ')'; it can itself contain spaces or parentheses, so it can't be
+	// parsed with a plain Fields() split.
+	open := strings.IndexByte(line, '(')
+	shut := strings.LastIndexByte(line, ')')
+	if open < 0 || shut < open {
+		return ProcStat{}, fmt.Errorf("malformed stat line: missing comm")
+	}
+	comm := line[open+1 : shut]
+
+	// Fields after the comm start at field 3 (state).
+	fields := strings.Fields(line[shut+1:])
+	const minFields = 24 - 3 + 1 // through field 24 (rss)
+	if len(fields) < minFields {
+		return ProcStat{}, fmt.Errorf("too few fields in stat line: got %d, want at least %d", len(fields), minFields)
+	}
+
+	field := func(n int) string { return fields[n-3] }
+
+	ppid, err := strconv.Atoi(field(4))
+	if err != nil {
+		return ProcStat{}, err
+	}
+	pgrp, err := strconv.Atoi(field(5))
+	if err != nil {
+		return ProcStat{}, err
+	}
+	utime, err := strconv.ParseUint(field(14), 10, 64)
+	if err != nil {
+		return ProcStat{}, err
+	}
+	stime, err := strconv.ParseUint(field(15), 10, 64)
+	if err != nil {
+		return ProcStat{}, err
+	}
+	startTime, err := strconv.ParseUint(field(22), 10, 64)
+	if err != nil {
+		return ProcStat{}, err
+	}
+	vsize, err := strconv.ParseUint(field(23), 10, 64)
+	if err != nil {
+		return ProcStat{}, err
+	}
+	rss, err := strconv.ParseInt(field(24), 10, 64)
+	if err != nil {
+		return ProcStat{}, err
+	}
+
+	return ProcStat{
+		Comm:      comm,
+		State:     field(3),
+		PPID:      ppid,
+		PGRP:      pgrp,
+		Utime:     utime,
+		Stime:     stime,
+		StartTime: startTime,
+		RSS:       rss,
+		VSize:     vsize,
+	}, nil
+}
+
+// Status reads and parses /proc/<pid>/status.
+func (p Proc) Status() (ProcStatus, error) {
+	data, err := ioutil.ReadFile(p.path("status"))
+	if err != nil {
+		return ProcStatus{}, err
+	}
+	status, err := parseProcStatus(string(data))
+	if err != nil {
+		return ProcStatus{}, fmt.Errorf("procfs: parsing status for pid %d: %w", p.PID, err)
+	}
+	return status, nil
+}
+
+func parseProcStatus(content string) (ProcStatus, error) {
+	var status ProcStatus
+	var err error
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := parts[0], strings.TrimSpace(parts[1])
+
+		switch key {
+		case "Uid":
+			status.Uid, err = parseInts(strings.Fields(value))
+		case "Gid":
+			status.Gid, err = parseInts(strings.Fields(value))
+		case "Groups":
+			status.Groups, err = parseInts(strings.Fields(value))
+		case "NSpid":
+			status.NSpid, err = parseInts(strings.Fields(value))
+		case "VmRSS":
+			status.VmRSS, err = strconv.ParseUint(strings.TrimSuffix(value, " kB"), 10, 64)
+		case "CapEff":
+			status.CapEff, err = strconv.ParseUint(value, 16, 64)
+		}
+		if err != nil {
+			return ProcStatus{}, fmt.Errorf("parsing %q: %w", key, err)
+		}
+	}
+	return status, nil
+}
+
+func parseInts(fields []string) ([]int, error) {
+	ints := make([]int, len(fields))
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		ints[i] = n
+	}
+	return ints, nil
+}
+
+// Cgroups reads and parses /proc/<pid>/cgroup into one Cgroup per line.
+func (p Proc) Cgroups() ([]Cgroup, error) {
+	data, err := ioutil.ReadFile(p.path("cgroup"))
+	if err != nil {
+		return nil, err
+	}
+
+	var cgroups []Cgroup
+	for _, line := range strings.Split(string(data), "\n") {
+		entries := strings.SplitN(line, ":", 3)
+		if len(entries) != 3 {
+			continue
+		}
+		hierarchyID, err := strconv.Atoi(entries[0])
+		if err != nil {
+			continue
+		}
+		var controllers []string
+		if entries[1] != "" {
+			controllers = strings.Split(entries[1], ",")
+		}
+		cgroups = append(cgroups, Cgroup{
+			HierarchyID: hierarchyID,
+			Controllers: controllers,
+			Path:        strings.TrimSpace(entries[2]),
+		})
+	}
+	return cgroups, nil
+}
+
+// Executable returns the resolved path of the process's executable, i.e. the
+// target of the /proc/<pid>/exe symlink.
+func (p Proc) Executable() (string, error) {
+	return os.Readlink(p.path("exe"))
+}
+
+// Root returns the resolved path of the process's filesystem root, i.e. the
+// target of the /proc/<pid>/root symlink.
+func (p Proc) Root() (string, error) {
+	return os.Readlink(p.path("root"))
+}
+
+// NamespaceInodes returns the inode number of each entry under
+// /proc/<pid>/ns, keyed by namespace kind (e.g. "net", "mnt", "pid"). Two
+// processes share a namespace of a given kind iff their inode numbers for
+// that kind match.
+func (p Proc) NamespaceInodes() (map[string]uint64, error) {
+	entries, err := ioutil.ReadDir(p.path("ns"))
+	if err != nil {
+		return nil, err
+	}
+
+	inodes := make(map[string]uint64, len(entries))
+	for _, entry := range entries {
+		info, err := os.Stat(p.path("ns", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil, fmt.Errorf("procfs: unsupported stat_t for %s", p.path("ns", entry.Name()))
+		}
+		inodes[entry.Name()] = stat.Ino
+	}
+	return inodes, nil
+}
+
+// Children returns the direct child processes of p, found by scanning all
+// processes on the system for a matching PPID. There is no cheaper way to do
+// this from outside the kernel: /proc/<pid>/task/<tid>/children depends on
+// CONFIG_CHECKPOINT_RESTORE and is unreliable across distributions.
+func (p Proc) Children() ([]Proc, error) {
+	procs, err := allProcs()
+	if err != nil {
+		return nil, err
+	}
+
+	var children []Proc
+	for _, child := range procs {
+		stat, err := child.Stat()
+		if err != nil {
+			// The process may have exited since we listed /proc; skip it.
+			continue
+		}
+		if stat.PPID == p.PID {
+			children = append(children, child)
+		}
+	}
+	return children, nil
+}
+
+// allProcs lists every process currently visible under /proc.
+func allProcs() ([]Proc, error) {
+	d, err := os.Open(procPath)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	procs := make([]Proc, 0, len(names))
+	for _, name := range names {
+		pid, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		procs = append(procs, Proc{PID: pid})
+	}
+	return procs, nil
+}
+
+// AllProcs lists every process currently visible under /proc.
+func (pfs *ProcFS) AllProcs() ([]Proc, error) {
+	return allProcs()
+}
+
+// Self returns a Proc for the calling process.
+func (pfs *ProcFS) Self() (Proc, error) {
+	return Proc{PID: os.Getpid()}, nil
+}
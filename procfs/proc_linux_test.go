@@ -0,0 +1,84 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProcStat(t *testing.T) {
+	// A real /proc/<pid>/stat line, with a comm containing a space, which a
+	// plain Fields() split of the whole line would break apart.
+	line := "1234 (my proc) S 1 1234 1234 0 -1 4194304 100 0 0 0 10 5 0 0 20 0 1 0 54321 123456 512 " +
+		"18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 1 0 0 0 0 0"
+
+	got, err := parseProcStat(line)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := ProcStat{
+		Comm:      "my proc",
+		State:     "S",
+		PPID:      1,
+		PGRP:      1234,
+		Utime:     10,
+		Stime:     5,
+		StartTime: 54321,
+		VSize:     123456,
+		RSS:       512,
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseProcStatMalformed(t *testing.T) {
+	if _, err := parseProcStat("not a stat line"); err == nil {
+		t.Fatal("expected an error for a line with no comm parentheses")
+	}
+}
+
+func TestParseProcStatus(t *testing.T) {
+	content := "Name:\tbash\n" +
+		"Uid:\t1000\t1000\t1000\t1000\n" +
+		"Gid:\t1000\t1000\t1000\t1000\n" +
+		"Groups:\t4 24 27 1000\n" +
+		"VmRSS:\t    4096 kB\n" +
+		"NSpid:\t54321\t1\n" +
+		"CapEff:\t0000003fffffffff\n"
+
+	got, err := parseProcStatus(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := ProcStatus{
+		Uid:    []int{1000, 1000, 1000, 1000},
+		Gid:    []int{1000, 1000, 1000, 1000},
+		Groups: []int{4, 24, 27, 1000},
+		VmRSS:  4096,
+		NSpid:  []int{54321, 1},
+		CapEff: 0x3fffffffff,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
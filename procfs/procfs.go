@@ -0,0 +1,33 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procfs
+
+// ProcInterface is an interface for querying /proc for information about
+// running processes. It is implemented by ProcFS.
+type ProcInterface interface {
+	// GetFullContainerName gets the container name given the root process id
+	// of the container. preferredSubsystem, if non-empty, names a cgroup v1
+	// subsystem (e.g. "memory", "pids", "cpu") to consult before falling back
+	// to "devices"; it is ignored on a pure cgroup v2 system.
+	GetFullContainerName(pid int, preferredSubsystem string) (string, error)
+
+	// ListContainerProcesses enters the PID and mount namespaces of the
+	// container whose root process is pid and returns one row per process
+	// visible from inside, each containing the requested columns (see
+	// RegisterColumn for the available names).
+	ListContainerProcesses(pid int, fields []string) ([]map[string]string, error)
+}
@@ -19,13 +19,11 @@ limitations under the License.
 package procfs
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path"
-	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -43,21 +41,64 @@ func NewProcFS() ProcInterface {
 	return &ProcFS{}
 }
 
-func containerNameFromProcCgroup(content string) (string, error) {
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
+// containerNameFromProcCgroup parses the contents of a /proc/<pid>/cgroup file.
+// Each line has the form "<hierarchy-id>:<controller-list>:<path>". On a
+// cgroup v1 system there is one line per mounted controller, e.g.
+// "4:devices:/docker/nginx". On a cgroup v2 (unified hierarchy) system there
+// is a single line with hierarchy id 0 and an empty controller list, e.g.
+// "0::/docker/nginx". On a hybrid system both kinds of lines can be present.
+//
+// The "devices" controller is preferred when present, since that is what
+// GetFullContainerName has historically returned. If preferredSubsystem is
+// non-empty it is tried before "devices", which lets callers on hybrid
+// systems pick a controller (e.g. "memory" or "pids") that is guaranteed to
+// be mounted even when "devices" is not. The v2 unified entry is used as the
+// final fallback.
+func containerNameFromProcCgroup(content string, preferredSubsystem string) (string, error) {
+	var unified string
+	subsystems := map[string]string{}
+
+	for _, line := range strings.Split(content, "\n") {
 		entries := strings.SplitN(line, ":", 3)
-		if len(entries) == 3 && entries[1] == "devices" {
-			return strings.TrimSpace(entries[2]), nil
+		if len(entries) != 3 {
+			continue
 		}
+		hierarchyID, controllers, cgroupPath := entries[0], entries[1], strings.TrimSpace(entries[2])
+		if hierarchyID == "0" && controllers == "" {
+			unified = cgroupPath
+			continue
+		}
+		for _, controller := range strings.Split(controllers, ",") {
+			if controller != "" {
+				subsystems[controller] = cgroupPath
+			}
+		}
+	}
+
+	if preferredSubsystem != "" {
+		if cgroupPath, ok := subsystems[preferredSubsystem]; ok {
+			return cgroupPath, nil
+		}
+	}
+	if cgroupPath, ok := subsystems["devices"]; ok {
+		return cgroupPath, nil
+	}
+	if unified != "" {
+		return unified, nil
 	}
+
 	return "", fmt.Errorf("could not find devices cgroup location")
 }
 
 // GetFullContainerName gets the container name given the root process id of the container.
 // E.g. if the devices cgroup for the container is stored in /sys/fs/cgroup/devices/docker/nginx,
 // return docker/nginx. Assumes that the process is part of exactly one cgroup hierarchy.
-func (pfs *ProcFS) GetFullContainerName(pid int) (string, error) {
+//
+// preferredSubsystem names a cgroup v1 subsystem to consult before falling
+// back to "devices"; pass "" to consult only "devices", then the cgroup v2
+// unified entry if "devices" is absent. It is ignored on a pure cgroup v2
+// system, where the single unified entry is used.
+func (pfs *ProcFS) GetFullContainerName(pid int, preferredSubsystem string) (string, error) {
 	filePath := path.Join("/proc", strconv.Itoa(pid), "cgroup")
 	content, err := ioutil.ReadFile(filePath)
 	if err != nil {
@@ -66,7 +107,7 @@ func (pfs *ProcFS) GetFullContainerName(pid int) (string, error) {
 		}
 		return "", err
 	}
-	return containerNameFromProcCgroup(string(content))
+	return containerNameFromProcCgroup(string(content), preferredSubsystem)
 }
 
 // PKill finds process(es) using a regular expression
@@ -79,7 +120,10 @@ func PKill(name string, sig syscall.Signal) error {
 	if err != nil {
 		return err
 	}
-	pids := getPids(re)
+	pids, err := pidsMatching(re)
+	if err != nil {
+		return err
+	}
 	if len(pids) == 0 {
 		return fmt.Errorf("unable to fetch pids for process name : %q", name)
 	}
@@ -109,72 +153,59 @@ func PKill(name string, sig syscall.Signal) error {
 // PidOf finds process(es) with a specified name (regexp match)
 // and returns their pid(s)
 func PidOf(name string) ([]int, error) {
-	if len(name) == 0 {
-		return []int{}, fmt.Errorf("name should not be empty")
+	re, err := pidOfPattern(name)
+	if err != nil {
+		return []int{}, err
 	}
-	re, err := regexp.Compile("(^|/)" + name + "$")
+	pids, err := pidsMatching(re)
 	if err != nil {
 		return []int{}, err
 	}
-	return getPids(re), nil
+	return pids, nil
 }
 
-func getPids(re *regexp.Regexp) []int {
-	pids := []int{}
-
-	dirFD, err := os.Open("/proc")
-	if err != nil {
-		return nil
+// pidOfPattern builds the anchored "match the executable's basename" regexp
+// shared by PidOf and FirstPidOf.
+func pidOfPattern(name string) (*regexp.Regexp, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("name should not be empty")
 	}
-	defer dirFD.Close()
-
-	for {
-		// Read a small number at a time in case there are many entries, we don't want to
-		// allocate a lot here.
-		ls, err := dirFD.Readdir(10)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil
-		}
-
-		for _, entry := range ls {
-			if !entry.IsDir() {
-				continue
-			}
-
-			// If the directory is not a number (i.e. not a PID), skip it
-			pid, err := strconv.Atoi(entry.Name())
-			if err != nil {
-				continue
-			}
-
-			cmdline, err := ioutil.ReadFile(filepath.Join("/proc", entry.Name(), "cmdline"))
-			if err != nil {
-				klog.V(4).Infof("Error reading file %s: %+v", filepath.Join("/proc", entry.Name(), "cmdline"), err)
-				continue
-			}
+	return regexp.Compile("(^|/)" + name + "$")
+}
 
-			// The bytes we read have '\0' as a separator for the command line
-			parts := bytes.SplitN(cmdline, []byte{0}, 2)
-			if len(parts) == 0 {
-				continue
-			}
-			// Split the command line itself we are interested in just the first part
-			exe := strings.FieldsFunc(string(parts[0]), func(c rune) bool {
-				return unicode.IsSpace(c) || c == ':'
-			})
-			if len(exe) == 0 {
-				continue
-			}
-			// Check if the name of the executable is what we are looking for
-			if re.MatchString(exe[0]) {
-				// Grab the PID from the directory path
-				pids = append(pids, pid)
-			}
+// pidsMatching walks every process currently visible under /proc via
+// WalkProcs and collects the PIDs whose cmdline matches re.
+func pidsMatching(re *regexp.Regexp) ([]int, error) {
+	var pids []int
+	err := WalkProcs(context.Background(), func(p Proc) (bool, bool) {
+		if cmdlineMatches(p, re) {
+			pids = append(pids, p.PID)
 		}
+		return false, false
+	})
+	if err != nil {
+		return nil, err
 	}
+	return pids, nil
+}
 
-	return pids
+// cmdlineMatches reports whether p's first cmdline argument - its executable
+// path - matches re.
+func cmdlineMatches(p Proc, re *regexp.Regexp) bool {
+	cmdline, err := p.Cmdline()
+	if err != nil {
+		klog.V(4).Infof("Error reading cmdline for pid %d: %+v", p.PID, err)
+		return false
+	}
+	if len(cmdline) == 0 {
+		return false
+	}
+	// Split the command line itself; we are interested in just the first part.
+	exe := strings.FieldsFunc(cmdline[0], func(c rune) bool {
+		return unicode.IsSpace(c) || c == ':'
+	})
+	if len(exe) == 0 {
+		return false
+	}
+	return re.MatchString(exe[0])
 }
@@ -0,0 +1,82 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procfs
+
+import "testing"
+
+func TestContainerNameFromProcCgroup(t *testing.T) {
+	tests := []struct {
+		name               string
+		content            string
+		preferredSubsystem string
+		want               string
+		wantErr            bool
+	}{
+		{
+			name: "pure v1",
+			content: "11:devices:/docker/abc\n" +
+				"10:memory:/docker/abc\n" +
+				"1:name=systemd:/docker/abc\n",
+			want: "/docker/abc",
+		},
+		{
+			name:    "pure v2",
+			content: "0::/docker/abc\n",
+			want:    "/docker/abc",
+		},
+		{
+			name: "hybrid without devices uses preferred subsystem",
+			content: "10:memory:/docker/abc\n" +
+				"1:name=systemd:/docker/abc\n" +
+				"0::/docker/abc\n",
+			preferredSubsystem: "memory",
+			want:               "/docker/abc",
+		},
+		{
+			name: "hybrid falls back to unified entry when preferred subsystem is absent",
+			content: "1:name=systemd:/docker/abc\n" +
+				"0::/docker/abc\n",
+			preferredSubsystem: "memory",
+			want:               "/docker/abc",
+		},
+		{
+			name:    "no recognizable entries",
+			content: "1:name=systemd:/docker/abc\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := containerNameFromProcCgroup(tt.content, tt.preferredSubsystem)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got result %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// procPath is the /proc mount WalkProcs and allProcs scan. It is a var
+// rather than a constant so tests and benchmarks can point it at a fake
+// directory tree instead of the real /proc.
+var procPath = "/proc"
+
+// walkBatchSize is how many directory entries WalkProcs reads from /proc at
+// a time. Readdirnames avoids an lstat per entry that Readdir would do, and
+// reading in bounded batches (rather than all of them at once) keeps memory
+// flat on hosts with tens of thousands of processes and gives the walk
+// natural points to check ctx between batches.
+const walkBatchSize = 256
+
+// ProcWalkError is returned by WalkProcs when the walk stops because of an
+// error rather than because filter asked it to. Partial is true if filter
+// already ran for at least one process before the error, so callers
+// accumulating results in their filter closure know whether what they have
+// is a partial scan rather than simply empty.
+type ProcWalkError struct {
+	Err     error
+	Partial bool
+}
+
+func (e *ProcWalkError) Error() string {
+	if e.Partial {
+		return fmt.Sprintf("procfs: walk of /proc stopped early after a partial scan: %v", e.Err)
+	}
+	return fmt.Sprintf("procfs: walk of /proc failed before visiting any process: %v", e.Err)
+}
+
+func (e *ProcWalkError) Unwrap() error { return e.Err }
+
+// WalkProcs calls filter once for every process currently visible under
+// /proc. filter's stop return value ends the walk immediately, letting
+// callers that only need the first match (see FirstPidOf) avoid scanning
+// the rest of /proc; keep is not interpreted by WalkProcs itself and exists
+// purely so filter reads naturally at the call site - any accumulation of
+// matches is the filter's own responsibility via closure.
+//
+// ctx is checked before each batch of walkBatchSize directory entries; once
+// it is done, the walk stops and returns a *ProcWalkError wrapping ctx.Err(). It also returns a *ProcWalkError, instead of silently
+// returning what was found so far, if reading /proc fails partway through.
+func WalkProcs(ctx context.Context, filter func(Proc) (keep bool, stop bool)) error {
+	d, err := os.Open(procPath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	visited := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return &ProcWalkError{Err: ctx.Err(), Partial: visited > 0}
+		default:
+		}
+
+		names, readErr := d.Readdirnames(walkBatchSize)
+		for _, name := range names {
+			pid, convErr := strconv.Atoi(name)
+			if convErr != nil {
+				continue
+			}
+			visited++
+			if _, stop := filter(Proc{PID: pid}); stop {
+				return nil
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return &ProcWalkError{Err: readErr, Partial: visited > 0}
+		}
+	}
+}
+
+// FirstPidOf is like PidOf but stops walking /proc as soon as one match is
+// found, instead of collecting every match.
+func FirstPidOf(name string) (int, error) {
+	if len(name) == 0 {
+		return 0, fmt.Errorf("name should not be empty")
+	}
+	re, err := pidOfPattern(name)
+	if err != nil {
+		return 0, err
+	}
+
+	var pid int
+	found := false
+	err = WalkProcs(context.Background(), func(p Proc) (bool, bool) {
+		if !cmdlineMatches(p, re) {
+			return false, false
+		}
+		pid, found = p.PID, true
+		return true, true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("unable to fetch pid for process name: %q", name)
+	}
+	return pid, nil
+}
@@ -0,0 +1,158 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+const benchProcSize = 50000
+
+// buildFakeProc populates dir with benchProcSize fake /proc/<pid> entries,
+// each with a cmdline file, so the walking benchmarks below don't need an
+// actual host with tens of thousands of processes. The process named
+// "target" is placed at pid targetPID; how far a scan walks before reaching
+// it depends on the order the filesystem returns directory entries in,
+// which need not be numeric.
+func buildFakeProc(b *testing.B, targetPID int) string {
+	b.Helper()
+	dir := b.TempDir()
+	for pid := 1; pid <= benchProcSize; pid++ {
+		if err := os.Mkdir(filepath.Join(dir, strconv.Itoa(pid)), 0755); err != nil {
+			b.Fatal(err)
+		}
+		cmdline := "/usr/bin/worker\x00"
+		if pid == targetPID {
+			cmdline = "/usr/bin/target\x00"
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(pid), "cmdline"), []byte(cmdline), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// legacyReaddirScan mirrors the original getPids implementation this package
+// used before WalkProcs existed: Readdir(10) (which lstats every entry) plus
+// a full, non-cancellable read of every cmdline file. It exists only so the
+// benchmarks below can show the improvement WalkProcs gives over it.
+func legacyReaddirScan(tb testing.TB, dir string, re *regexp.Regexp) []int {
+	dirFD, err := os.Open(dir)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer dirFD.Close()
+
+	var pids []int
+	for {
+		entries, err := dirFD.Readdir(10)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			tb.Fatal(err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			pid, err := strconv.Atoi(entry.Name())
+			if err != nil {
+				continue
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name(), "cmdline"))
+			if err != nil {
+				continue
+			}
+			parts := bytes.SplitN(data, []byte{0}, 2)
+			if len(parts) > 0 && re.Match(parts[0]) {
+				pids = append(pids, pid)
+			}
+		}
+	}
+	return pids
+}
+
+func BenchmarkLegacyReaddirFullScan(b *testing.B) {
+	dir := buildFakeProc(b, benchProcSize)
+	re := regexp.MustCompile("target$")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		legacyReaddirScan(b, dir, re)
+	}
+}
+
+func BenchmarkWalkProcsFullScan(b *testing.B) {
+	dir := buildFakeProc(b, benchProcSize)
+	restore := setProcPath(dir)
+	defer restore()
+
+	re := regexp.MustCompile("target$")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var pids []int
+		if err := WalkProcs(context.Background(), func(p Proc) (bool, bool) {
+			if cmdlineMatches(p, re) {
+				pids = append(pids, p.PID)
+			}
+			return false, false
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkWalkProcsEarlyExit puts the sought-after process at pid 1 and
+// returns stop=true on the first match, so WalkProcs returns there without
+// reading the directory entries that come after it.
+func BenchmarkWalkProcsEarlyExit(b *testing.B) {
+	dir := buildFakeProc(b, 1)
+	restore := setProcPath(dir)
+	defer restore()
+
+	re := regexp.MustCompile("target$")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var pid int
+		if err := WalkProcs(context.Background(), func(p Proc) (bool, bool) {
+			if !cmdlineMatches(p, re) {
+				return false, false
+			}
+			pid = p.PID
+			return true, true
+		}); err != nil {
+			b.Fatal(err)
+		}
+		_ = pid
+	}
+}
+
+func setProcPath(dir string) (restore func()) {
+	original := procPath
+	procPath = dir
+	return func() { procPath = original }
+}
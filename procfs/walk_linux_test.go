@@ -0,0 +1,91 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func withFakeProc(t *testing.T, pids ...int) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, pid := range pids {
+		if err := os.Mkdir(filepath.Join(dir, strconv.Itoa(pid)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	original := procPath
+	procPath = dir
+	t.Cleanup(func() { procPath = original })
+}
+
+func TestWalkProcsVisitsEveryPid(t *testing.T) {
+	withFakeProc(t, 1, 2, 3)
+
+	var seen []int
+	err := WalkProcs(context.Background(), func(p Proc) (bool, bool) {
+		seen = append(seen, p.PID)
+		return true, false
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != 3 {
+		t.Fatalf("got %d pids, want 3: %v", len(seen), seen)
+	}
+}
+
+func TestWalkProcsStopsEarly(t *testing.T) {
+	withFakeProc(t, 1, 2, 3)
+
+	visited := 0
+	err := WalkProcs(context.Background(), func(p Proc) (bool, bool) {
+		visited++
+		return true, true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if visited != 1 {
+		t.Fatalf("got %d visits, want 1 since the filter asked to stop", visited)
+	}
+}
+
+func TestWalkProcsHonorsCancellation(t *testing.T) {
+	withFakeProc(t, 1, 2, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := WalkProcs(ctx, func(p Proc) (bool, bool) {
+		return true, false
+	})
+	var walkErr *ProcWalkError
+	if !errors.As(err, &walkErr) {
+		t.Fatalf("got %v, want a *ProcWalkError wrapping context.Canceled", err)
+	}
+	if !errors.Is(walkErr, context.Canceled) {
+		t.Errorf("got %v, want it to wrap context.Canceled", walkErr)
+	}
+}